@@ -1,6 +1,7 @@
 package server
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -10,14 +11,18 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gogo/protobuf/types"
+	"github.com/prometheus/client_golang/prometheus"
 	logrus "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 
 	ec "github.com/pachyderm/pachyderm/src/client/enterprise"
+	"github.com/pachyderm/pachyderm/src/client/version"
 	"github.com/pachyderm/pachyderm/src/server/pkg/backoff"
 	col "github.com/pachyderm/pachyderm/src/server/pkg/collection"
 	"github.com/pachyderm/pachyderm/src/server/pkg/log"
@@ -28,7 +33,103 @@ import (
 const (
 	enterprisePrefix = "/enterprise"
 
-	publicKey = `-----BEGIN PUBLIC KEY-----
+	// legacyRootKID identifies the original, single hardcoded root key below
+	// for activation codes that predate the signing key directory and don't
+	// carry a RootKID of their own.
+	legacyRootKID = "legacy"
+
+	// enterpriseTokenKey is the constant key we use that maps to an Enterprise
+	// token that a user has given us. This is what we check to know if a
+	// Pachyderm cluster supports enterprise features
+	enterpriseTokenKey = "token"
+
+	// heartbeatInterval is how often pachd checks in with a configured
+	// license server for a renewed activation code.
+	heartbeatInterval = time.Hour
+
+	// heartbeatTimeout bounds how long a single heartbeat POST may take, so
+	// an unresponsive license server can't wedge watchLicenseServer's loop
+	// for the life of the process.
+	heartbeatTimeout = 30 * time.Second
+)
+
+// heartbeatClient is used for all heartbeat POSTs to the license server. It's
+// separate from http.DefaultClient so it can carry its own timeout.
+var heartbeatClient = &http.Client{
+	Timeout: heartbeatTimeout,
+}
+
+// warningThresholds are the remaining-time thresholds at which WatchState
+// subscribers get an unprompted state push, so dashboards and pachctl can
+// warn admins well before a license actually lapses.
+var warningThresholds = []time.Duration{
+	30 * 24 * time.Hour,
+	7 * 24 * time.Hour,
+	24 * time.Hour,
+}
+
+var (
+	enterpriseExpirationSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pachyderm_enterprise_expiration_seconds",
+		Help: "Seconds until the current Pachyderm Enterprise token expires; negative if already expired.",
+	})
+	enterpriseStateGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pachyderm_enterprise_state",
+		Help: "Current Pachyderm Enterprise state: 0=NONE, 1=ACTIVE, 2=EXPIRED.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(enterpriseExpirationSeconds)
+	prometheus.MustRegister(enterpriseStateGauge)
+}
+
+// updateEnterpriseMetrics refreshes the Prometheus gauges from record, the
+// same EnterpriseRecord cached in enterpriseExpiration. It runs whenever the
+// cached record changes and each time watchExpirationWarnings' timer fires (at
+// each warning threshold, at expiration, and hourly after that); between
+// refreshes the gauges hold the values from the most recent one.
+func updateEnterpriseMetrics(record *ec.EnterpriseRecord) {
+	expiration, err := types.TimestampFromProto(record.Expires)
+	if err != nil {
+		return
+	}
+	if expiration.IsZero() {
+		enterpriseExpirationSeconds.Set(0)
+		enterpriseStateGauge.Set(float64(ec.State_NONE))
+		return
+	}
+	enterpriseExpirationSeconds.Set(time.Until(expiration).Seconds())
+	if time.Now().After(expiration) {
+		enterpriseStateGauge.Set(float64(ec.State_EXPIRED))
+	} else {
+		enterpriseStateGauge.Set(float64(ec.State_ACTIVE))
+	}
+}
+
+// signingKey is one entry in the embedded root signing key directory: a root
+// key used to pin a token's signer policy, along with the window in which
+// it's valid to verify against. Rotating the root key means appending a new
+// entry here and, once all outstanding licenses use it, closing out the old
+// one's NotAfter.
+type signingKey struct {
+	KID                string
+	NotBefore, NotAfter time.Time
+	PEM                 string
+}
+
+// signingKeys is the embedded directory of root keys that may sign a token's
+// signer policy. Keys are never removed, only closed out via NotAfter, so
+// that ListSigningKeys can still report on licenses signed with retired
+// keys.
+var signingKeys = []signingKey{
+	{
+		// The original root key, hardcoded before key rotation existed.
+		// Activation codes with no RootKID are assumed to use this one.
+		KID:       legacyRootKID,
+		NotBefore: time.Time{},
+		NotAfter:  time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		PEM: `-----BEGIN PUBLIC KEY-----
 MIICIjANBgkqhkiG9w0BAQEFAAOCAg8AMIICCgKCAgEAtJnDuD05fJZVsWDvN/un
 m5xbG7jcmxUsSOQZfvMaafZjV6iG/z6Wst2uhcMGAMrLHBxFiRYiVVM3kbUhbfbw
 3nVzALDLh4l/QzovCcF12FzVY8fB5Q6VQFfnup1aKimyJX7/au0ihvv//olQ1xrL
@@ -42,13 +143,30 @@ XinORcb47IsWIHXtwHcwY1C7kV0IK3DxJrJZsSib171vAwi6q/HSOSkWxCURsOtK
 x90hW9XbejJCpAiOYfPEOq0lT8fy1Ve0qBen1y4mcxtnXANrgQyYCCBftoc7Ctkk
 m5MuBYYSa4PH/uIZktTYOkMCAwEAAQ==
 -----END PUBLIC KEY-----
-`
+`,
+	},
+}
 
-	// enterpriseTokenKey is the constant key we use that maps to an Enterprise
-	// token that a user has given us. This is what we check to know if a
-	// Pachyderm cluster supports enterprise features
-	enterpriseTokenKey = "token"
-)
+// lookupSigningKey finds the root key with the given KID, treating the empty
+// string as the legacy key for back-compat with pre-rotation activation
+// codes. It returns an error if the KID is unknown or the key isn't valid at
+// the current time.
+func lookupSigningKey(kid string) (signingKey, error) {
+	if kid == "" {
+		kid = legacyRootKID
+	}
+	for _, k := range signingKeys {
+		if k.KID != kid {
+			continue
+		}
+		now := time.Now()
+		if now.Before(k.NotBefore) || now.After(k.NotAfter) {
+			return signingKey{}, fmt.Errorf("signing key %q is outside its validity window", kid)
+		}
+		return k, nil
+	}
+	return signingKey{}, fmt.Errorf("unknown signing key %q", kid)
+}
 
 type apiServer struct {
 	pachLogger log.Logger
@@ -65,6 +183,16 @@ type apiServer struct {
 	// enterpriseToken is a collection containing at most one Pachyderm enterprise
 	// token
 	enterpriseToken col.Collection
+
+	// stateSubscribers is the set of WatchState callers waiting on a state
+	// push, keyed by an opaque subscriber ID. Values are chan *ec.GetStateResponse.
+	stateSubscribers sync.Map
+	nextSubscriberID int64
+
+	// expirationChanged is signaled (non-blocking) whenever watchEnterpriseToken
+	// observes a new record, so watchExpirationWarnings can rearm its timer
+	// against the new expiration right away instead of waiting out a stale one.
+	expirationChanged chan struct{}
 }
 
 func (a *apiServer) LogReq(request interface{}) {
@@ -89,9 +217,15 @@ func NewEnterpriseServer(env *serviceenv.ServiceEnv, etcdPrefix string) (ec.APIS
 			nil,
 		),
 		defaultEnterpriseRecord: &ec.EnterpriseRecord{Expires: defaultExpires},
+		expirationChanged:       make(chan struct{}, 1),
 	}
 	s.enterpriseExpiration.Store(s.defaultEnterpriseRecord)
+	updateEnterpriseMetrics(s.defaultEnterpriseRecord)
 	go s.watchEnterpriseToken(etcdPrefix)
+	go s.watchExpirationWarnings()
+	if licenseServerURL := env.Config().LicenseServerURL; licenseServerURL != "" {
+		go s.watchLicenseServer(licenseServerURL)
+	}
 	return s, nil
 }
 
@@ -118,10 +252,12 @@ func (a *apiServer) watchEnterpriseToken(etcdPrefix string) {
 					return err
 				}
 				a.enterpriseExpiration.Store(record)
+				a.onRecordChanged(record)
 			case watch.EventDelete:
 				// This should only occur if the etcd value is deleted via the etcd API,
 				// but that does occur during testing
 				a.enterpriseExpiration.Store(a.defaultEnterpriseRecord)
+				a.onRecordChanged(a.defaultEnterpriseRecord)
 			case watch.EventError:
 				return ev.Err
 			}
@@ -132,9 +268,130 @@ func (a *apiServer) watchEnterpriseToken(etcdPrefix string) {
 	})
 }
 
+// onRecordChanged refreshes the Prometheus gauges, pushes the new state to
+// every WatchState subscriber, and wakes watchExpirationWarnings so it can
+// rearm its timer against the new expiration.
+func (a *apiServer) onRecordChanged(record *ec.EnterpriseRecord) {
+	updateEnterpriseMetrics(record)
+	a.broadcastState()
+	select {
+	case a.expirationChanged <- struct{}{}:
+	default:
+		// A rearm is already pending; watchExpirationWarnings will pick up
+		// the new expiration when it processes it.
+	}
+}
+
+// broadcastState computes the current GetStateResponse and pushes it to
+// every registered WatchState subscriber, dropping the update for any
+// subscriber whose buffered channel is full rather than blocking on it.
+func (a *apiServer) broadcastState() {
+	resp, err := a.GetState(context.Background(), &ec.GetStateRequest{})
+	if err != nil {
+		logrus.Printf("error computing enterprise state to broadcast: %v", err)
+		return
+	}
+	a.stateSubscribers.Range(func(_, value interface{}) bool {
+		ch := value.(chan *ec.GetStateResponse)
+		select {
+		case ch <- resp:
+		default:
+		}
+		return true
+	})
+}
+
+// nextWarningDeadline returns how long to wait before the next WatchState
+// push is due: either the next warning threshold or the expiration itself,
+// whichever comes first. If there's no live token, it just polls slowly.
+func nextWarningDeadline(record *ec.EnterpriseRecord) time.Duration {
+	expiration, err := types.TimestampFromProto(record.Expires)
+	if err != nil || expiration.IsZero() {
+		return time.Hour
+	}
+	now := time.Now()
+	if now.After(expiration) {
+		// Already expired: the expiration crossing has already been pushed
+		// (or is about to be, by the timer fire that got us here), and every
+		// warning threshold is in the past too. There's nothing left to wait
+		// for, so fall back to the same slow poll as the no-live-token case.
+		return time.Hour
+	}
+	next := expiration
+	for _, threshold := range warningThresholds {
+		if crossing := expiration.Add(-threshold); crossing.After(now) && crossing.Before(next) {
+			next = crossing
+		}
+	}
+	return next.Sub(now)
+}
+
+// watchExpirationWarnings owns a single timer that fires at each configured
+// warning threshold before the live token's expiration, and at expiration
+// itself, pushing a state update to every WatchState subscriber each time.
+// It rearms whenever onRecordChanged signals that the cached record changed.
+func (a *apiServer) watchExpirationWarnings() {
+	record, _ := a.enterpriseExpiration.Load().(*ec.EnterpriseRecord)
+	timer := time.NewTimer(nextWarningDeadline(record))
+	defer timer.Stop()
+	for {
+		select {
+		case <-a.expirationChanged:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			record, _ := a.enterpriseExpiration.Load().(*ec.EnterpriseRecord)
+			timer.Reset(nextWarningDeadline(record))
+		case <-timer.C:
+			a.broadcastState()
+			record, _ := a.enterpriseExpiration.Load().(*ec.EnterpriseRecord)
+			updateEnterpriseMetrics(record)
+			timer.Reset(nextWarningDeadline(record))
+		}
+	}
+}
+
+// signerSignature is a single signer's RSA-SHA256 signature over the
+// activation code's token bytes.
+type signerSignature struct {
+	KID       string
+	Signature string
+}
+
 type activationCode struct {
-	Token     string
+	Token      string
+	Signatures []signerSignature
+	// Signature is the pre-multi-signer field name for a single signature
+	// over Token, verified directly against the root key rather than
+	// against a signer policy. Populated on activation codes issued before
+	// multi-signer support; new codes carry Signatures instead and leave this
+	// empty. Kept so those old codes keep validating rather than silently
+	// breaking every customer license issued under the old scheme.
 	Signature string
+	// RootKID identifies which entry in signingKeys signed the token's
+	// signer policy. Omitted (or empty) for codes issued before key
+	// rotation, which are verified against legacyRootKID.
+	RootKID string
+}
+
+// signerKey is one entry in a token's signer policy: an authorized signer's
+// key ID and PEM-encoded RSA public key.
+type signerKey struct {
+	KID string
+	Pub string
+}
+
+// signerPolicy is the set of signers authorized to sign activation codes,
+// along with the threshold of distinct signers required. It is embedded in
+// the token and is itself pinned to a root key via PolicySignature, so that
+// a forged or stale policy can't be smuggled in through the token.
+type signerPolicy struct {
+	Signers         []signerKey
+	Threshold       int
+	PolicySignature string
 }
 
 // token is used to parse a JSON object generated by Pachyderm Inc's enterprise
@@ -143,68 +400,352 @@ type activationCode struct {
 // of Pachyderm's customers (if you're changing or removing a field).
 type token struct {
 	Expiry string
+	// Features is the set of capability names this token unlocks, e.g.
+	// "auth" or "object-storage-tiering". A nil or empty Features means no
+	// capabilities beyond the base product.
+	Features []string
+	// Limits maps quota names, e.g. "max_pipelines", to their numeric value.
+	Limits map[string]int64
+	signerPolicy
 }
 
-// validateActivationCode checks the validity of an activation code
-func validateActivationCode(code string) (expiration time.Time, err error) {
-	// Parse the public key.  If these steps fail, something is seriously
-	// wrong and we should crash the service by panicking.
-	block, _ := pem.Decode([]byte(publicKey))
+// parseRSAPublicKey PEM-decodes and parses an RSA public key, as used for
+// both the pinned root key and the per-signer keys embedded in a token.
+func parseRSAPublicKey(pemStr string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(pemStr))
 	if block == nil {
-		return time.Time{}, fmt.Errorf("failed to pem decode public key")
+		return nil, fmt.Errorf("failed to pem decode public key")
 	}
 	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("failed to parse DER encoded public key: %s", err.Error())
+		return nil, fmt.Errorf("failed to parse DER encoded public key: %s", err.Error())
 	}
 	rsaPub, ok := pub.(*rsa.PublicKey)
 	if !ok {
-		return time.Time{}, fmt.Errorf("public key isn't an RSA key")
+		return nil, fmt.Errorf("public key isn't an RSA key")
+	}
+	return rsaPub, nil
+}
+
+// validatePolicy checks that the signer policy embedded in a token was
+// itself signed by the root key identified by rootKID, so that the set of
+// authorized signers and the threshold can't be tampered with independently
+// of the root of trust.
+func validatePolicy(policy signerPolicy, rootKID string) error {
+	rootKey, err := lookupSigningKey(rootKID)
+	if err != nil {
+		return fmt.Errorf("could not verify signer policy: %s", err.Error())
+	}
+	rootPub, err := parseRSAPublicKey(rootKey.PEM)
+	if err != nil {
+		return fmt.Errorf("failed to parse root public key: %s", err.Error())
+	}
+	decodedSignature, err := base64.StdEncoding.DecodeString(policy.PolicySignature)
+	if err != nil {
+		return fmt.Errorf("policy signature is not base64 encoded")
 	}
+	unsigned := signerPolicy{Signers: policy.Signers, Threshold: policy.Threshold}
+	policyBytes, err := json.Marshal(unsigned)
+	if err != nil {
+		return fmt.Errorf("could not marshal signer policy: %s", err.Error())
+	}
+	hashedPolicy := sha256.Sum256(policyBytes)
+	if err := rsa.VerifyPKCS1v15(rootPub, crypto.SHA256, hashedPolicy[:], decodedSignature); err != nil {
+		return fmt.Errorf("invalid root signature over signer policy")
+	}
+	return nil
+}
 
+// validatedActivationCode is everything validateActivationCode extracts from
+// an activation code once it's been verified. It's returned as a struct
+// rather than a long list of named returns now that the token carries both
+// signing metadata and capability scoping.
+type validatedActivationCode struct {
+	Expiration        time.Time
+	RootKID           string
+	AuthorizedSigners []string
+	Features          []string
+	Limits            map[string]int64
+}
+
+// validateLegacyActivationCode validates an activation code issued under the
+// original single-signer scheme, which predates signer policies entirely: the
+// token itself is signed directly with the root key identified by rootKID,
+// with no policy or threshold to check.
+func validateLegacyActivationCode(code *activationCode, tok token, rootKID string) (*validatedActivationCode, error) {
+	rootKey, err := lookupSigningKey(rootKID)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify activation code: %s", err.Error())
+	}
+	rootPub, err := parseRSAPublicKey(rootKey.PEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse root public key: %s", err.Error())
+	}
+	decodedSignature, err := base64.StdEncoding.DecodeString(code.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("signature is not base64 encoded")
+	}
+	hashedToken := sha256.Sum256([]byte(code.Token))
+	if err := rsa.VerifyPKCS1v15(rootPub, crypto.SHA256, hashedToken[:], decodedSignature); err != nil {
+		return nil, fmt.Errorf("invalid signature in activation code")
+	}
+	expiration, err := time.Parse(time.RFC3339, tok.Expiry)
+	if err != nil {
+		return nil, fmt.Errorf("expiration is not valid ISO 8601 string")
+	}
+	if time.Now().After(expiration) {
+		return nil, fmt.Errorf("the activation code has expired")
+	}
+	return &validatedActivationCode{
+		Expiration:        expiration,
+		RootKID:           rootKID,
+		AuthorizedSigners: []string{rootKID},
+		Features:          tok.Features,
+		Limits:            tok.Limits,
+	}, nil
+}
+
+// validateActivationCode checks the validity of an activation code and
+// returns everything it authorizes: its expiration, the KID of the root key
+// that pinned the signer policy, the set of signer KIDs whose signatures
+// were verified (a subset of at least size policy.Threshold), and the
+// capability set and quotas the code grants.
+func validateActivationCode(code string) (*validatedActivationCode, error) {
 	// Decode the base64-encoded activation code
 	decodedActivationCode, err := base64.StdEncoding.DecodeString(code)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("activation code is not base64 encoded")
+		return nil, fmt.Errorf("activation code is not base64 encoded")
 	}
 	activationCode := &activationCode{}
 	if err := json.Unmarshal(decodedActivationCode, &activationCode); err != nil {
-		return time.Time{}, fmt.Errorf("activation code is not valid JSON")
+		return nil, fmt.Errorf("activation code is not valid JSON")
+	}
+	rootKID := activationCode.RootKID
+	if rootKID == "" {
+		rootKID = legacyRootKID
 	}
 
-	// Decode the signature
-	decodedSignature, err := base64.StdEncoding.DecodeString(activationCode.Signature)
-	if err != nil {
-		return time.Time{}, fmt.Errorf("signature is not base64 encoded")
+	// Unmarshal the token
+	token := token{}
+	if err := json.Unmarshal([]byte(activationCode.Token), &token); err != nil {
+		return nil, fmt.Errorf("token is not valid JSON")
 	}
 
-	// Compute the sha256 checksum of the token
-	hashedToken := sha256.Sum256([]byte(activationCode.Token))
+	// Pre-multi-signer activation codes carry a single Signature made
+	// directly against the root key, with no signer policy embedded in the
+	// token at all. Validate those the same way the original single-signer
+	// scheme did, rather than running them through validatePolicy, which
+	// would reject them for having no policy to pin.
+	if len(activationCode.Signatures) == 0 && activationCode.Signature != "" {
+		return validateLegacyActivationCode(activationCode, token, rootKID)
+	}
 
-	// Verify that the signature is valid
-	if err := rsa.VerifyPKCS1v15(rsaPub, crypto.SHA256, hashedToken[:], decodedSignature); err != nil {
-		return time.Time{}, fmt.Errorf("invalid signature in activation code")
+	// Pin the embedded signer policy to the root key identified by RootKID
+	if err := validatePolicy(token.signerPolicy, activationCode.RootKID); err != nil {
+		return nil, err
 	}
 
-	// Unmarshal the token
-	token := token{}
-	if err := json.Unmarshal([]byte(activationCode.Token), &token); err != nil {
-		return time.Time{}, fmt.Errorf("token is not valid JSON")
+	// Compute the sha256 checksum of the token, which is what each signer
+	// signs
+	hashedToken := sha256.Sum256([]byte(activationCode.Token))
+
+	// Verify each signature against the corresponding signer in the policy,
+	// tracking the distinct, valid signer KIDs
+	signerKeys := make(map[string]string) // KID -> PEM public key
+	for _, s := range token.Signers {
+		signerKeys[s.KID] = s.Pub
+	}
+	var authorizedSigners []string
+	seen := make(map[string]bool)
+	for _, sig := range activationCode.Signatures {
+		pemKey, ok := signerKeys[sig.KID]
+		if !ok || seen[sig.KID] {
+			continue
+		}
+		signerPub, err := parseRSAPublicKey(pemKey)
+		if err != nil {
+			continue
+		}
+		decodedSignature, err := base64.StdEncoding.DecodeString(sig.Signature)
+		if err != nil {
+			continue
+		}
+		if err := rsa.VerifyPKCS1v15(signerPub, crypto.SHA256, hashedToken[:], decodedSignature); err != nil {
+			continue
+		}
+		seen[sig.KID] = true
+		authorizedSigners = append(authorizedSigners, sig.KID)
+	}
+	if len(authorizedSigners) < token.Threshold {
+		return nil, fmt.Errorf("activation code has %d valid signature(s), but the signer policy requires %d", len(authorizedSigners), token.Threshold)
 	}
 
 	// Parse the expiration. Note that this string is generated by Date.toJSON()
 	// running in node, so Go's definition of RFC 3339 timestamps (which is
 	// incomplete) must be compatible with the strings that node generates. So far
 	// it seems to work.
-	expiration, err = time.Parse(time.RFC3339, token.Expiry)
+	expiration, err := time.Parse(time.RFC3339, token.Expiry)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("expiration is not valid ISO 8601 string")
+		return nil, fmt.Errorf("expiration is not valid ISO 8601 string")
 	}
 	// Check that the activation code has not expired
 	if time.Now().After(expiration) {
-		return time.Time{}, fmt.Errorf("the activation code has expired")
+		return nil, fmt.Errorf("the activation code has expired")
+	}
+	return &validatedActivationCode{
+		Expiration:        expiration,
+		RootKID:           rootKID,
+		AuthorizedSigners: authorizedSigners,
+		Features:          token.Features,
+		Limits:            token.Limits,
+	}, nil
+}
+
+// heartbeatRequest is the payload pachd sends a configured license server on
+// each heartbeat tick: enough for the server to decide whether to issue a
+// renewed activation code. Besides the cluster ID, usage is reported only as
+// aggregate counts.
+type heartbeatRequest struct {
+	ClusterID       string
+	Version         string
+	Expiration      string // RFC3339, same format as token.Expiry
+	PipelineCount   int64
+	ActiveUserCount int64
+}
+
+// heartbeatResponse optionally carries a freshly signed activation code that
+// extends the cluster's current expiration.
+type heartbeatResponse struct {
+	ActivationCode string
+}
+
+// watchLicenseServer periodically heartbeats a configured license server so
+// short-lived activation codes can be renewed without operator intervention.
+// Unlike watchEnterpriseToken, a failed heartbeat just gets logged and
+// retried on the next tick rather than backed off, since missing a single
+// heartbeat isn't fatal.
+func (a *apiServer) watchLicenseServer(licenseServerURL string) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := a.heartbeat(context.Background(), licenseServerURL); err != nil {
+			logrus.Printf("error sending license server heartbeat: %v", err)
+		}
+	}
+}
+
+// collectUsageCounters gathers the anonymized usage counters sent in a
+// heartbeat. The active user count is the number of cluster admins returned
+// by ListAdmins; other users are not counted. Auth may not be enabled on this
+// cluster, in which case (or on any other ListAdmins error) it is reported as 0.
+func (a *apiServer) collectUsageCounters(ctx context.Context) (pipelineCount, activeUserCount int64, err error) {
+	pachClient := a.env.GetPachClient(ctx)
+	pipelineInfos, err := pachClient.ListPipeline()
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not list pipelines: %s", err.Error())
+	}
+	pipelineCount = int64(len(pipelineInfos))
+	admins, err := pachClient.ListAdmins()
+	if err != nil {
+		return pipelineCount, 0, nil
+	}
+	return pipelineCount, int64(len(admins)), nil
+}
+
+// heartbeat POSTs a single heartbeat to licenseServerURL and, if it comes
+// back with an activation code that strictly extends the current
+// expiration, validates and persists it exactly like Activate. It backs
+// both the periodic watchLicenseServer loop and the synchronous Renew RPC.
+func (a *apiServer) heartbeat(ctx context.Context, licenseServerURL string) (*ec.TokenInfo, error) {
+	record, ok := a.enterpriseExpiration.Load().(*ec.EnterpriseRecord)
+	if !ok {
+		return nil, fmt.Errorf("could not retrieve enterprise expiration time")
+	}
+	currentExpiration, err := types.TimestampFromProto(record.Expires)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse expiration timestamp: %s", err.Error())
+	}
+	pipelineCount, activeUserCount, err := a.collectUsageCounters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reqBody, err := json.Marshal(heartbeatRequest{
+		ClusterID:       a.env.ClusterID(),
+		Version:         version.PrettyPrintVersion(version.Version),
+		Expiration:      currentExpiration.Format(time.RFC3339),
+		PipelineCount:   pipelineCount,
+		ActiveUserCount: activeUserCount,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal heartbeat request: %s", err.Error())
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, licenseServerURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("could not build heartbeat request: %s", err.Error())
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := heartbeatClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach license server: %s", err.Error())
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("license server returned status %d", httpResp.StatusCode)
+	}
+	heartbeatResp := heartbeatResponse{}
+	if err := json.NewDecoder(httpResp.Body).Decode(&heartbeatResp); err != nil {
+		return nil, fmt.Errorf("could not decode license server response: %s", err.Error())
+	}
+	if heartbeatResp.ActivationCode == "" {
+		// No renewal offered this round; nothing to do.
+		return &ec.TokenInfo{Expires: record.Expires}, nil
+	}
+
+	validated, err := validateActivationCode(heartbeatResp.ActivationCode)
+	if err != nil {
+		return nil, fmt.Errorf("license server returned an invalid activation code: %s", err.Error())
+	}
+	if !validated.Expiration.After(currentExpiration) {
+		return nil, fmt.Errorf("license server heartbeat did not extend the current expiration")
+	}
+	expirationProto, err := types.TimestampProto(validated.Expiration)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert expiration time \"%s\" to proto: %s", validated.Expiration.String(), err.Error())
+	}
+	if _, err := col.NewSTM(ctx, a.env.GetEtcdClient(), func(stm col.STM) error {
+		e := a.enterpriseToken.ReadWrite(stm)
+		return e.Put(enterpriseTokenKey, &ec.EnterpriseRecord{
+			ActivationCode:    heartbeatResp.ActivationCode,
+			Expires:           expirationProto,
+			AuthorizedSigners: validated.AuthorizedSigners,
+			RootKID:           validated.RootKID,
+			Features:          validated.Features,
+			Limits:            validated.Limits,
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	// Wait until watcher observes the write
+	if err := backoff.Retry(func() error {
+		cached, ok := a.enterpriseExpiration.Load().(*ec.EnterpriseRecord)
+		if !ok {
+			return fmt.Errorf("could not retrieve enterprise expiration time")
+		}
+		cachedExpiration, err := types.TimestampFromProto(cached.Expires)
+		if err != nil {
+			return fmt.Errorf("could not parse expiration timestamp: %s", err.Error())
+		}
+		if !cachedExpiration.After(currentExpiration) {
+			return fmt.Errorf("renewed enterprise token not yet observed")
+		}
+		return nil
+	}, backoff.RetryEvery(time.Second)); err != nil {
+		return nil, err
 	}
-	return expiration, nil
+	time.Sleep(time.Second) // give other pachd nodes time to observe the write
+
+	return &ec.TokenInfo{Expires: expirationProto}, nil
 }
 
 // Activate implements the Activate RPC
@@ -213,10 +754,11 @@ func (a *apiServer) Activate(ctx context.Context, req *ec.ActivateRequest) (resp
 	defer func(start time.Time) { a.pachLogger.Log(req, resp, retErr, time.Since(start)) }(time.Now())
 
 	// Validate the activation code
-	expiration, err := validateActivationCode(req.ActivationCode)
+	validated, err := validateActivationCode(req.ActivationCode)
 	if err != nil {
 		return nil, fmt.Errorf("error validating activation code: %s", err.Error())
 	}
+	expiration := validated.Expiration
 	// Allow request to override expiration in the activation code, for testing
 	if req.Expires != nil {
 		customExpiration, err := types.TimestampFromProto(req.Expires)
@@ -232,8 +774,12 @@ func (a *apiServer) Activate(ctx context.Context, req *ec.ActivateRequest) (resp
 		e := a.enterpriseToken.ReadWrite(stm)
 		// blind write
 		return e.Put(enterpriseTokenKey, &ec.EnterpriseRecord{
-			ActivationCode: req.ActivationCode,
-			Expires:        expirationProto,
+			ActivationCode:    req.ActivationCode,
+			Expires:           expirationProto,
+			AuthorizedSigners: validated.AuthorizedSigners,
+			RootKID:           validated.RootKID,
+			Features:          validated.Features,
+			Limits:            validated.Limits,
 		})
 	}); err != nil {
 		return nil, err
@@ -265,6 +811,24 @@ func (a *apiServer) Activate(ctx context.Context, req *ec.ActivateRequest) (resp
 	}, nil
 }
 
+// Renew synchronously runs one license-server heartbeat and returns the
+// resulting token info, for operators who don't want to wait for the next
+// scheduled heartbeat.
+func (a *apiServer) Renew(ctx context.Context, req *ec.RenewRequest) (resp *ec.RenewResponse, retErr error) {
+	a.LogReq(req)
+	defer func(start time.Time) { a.pachLogger.Log(req, resp, retErr, time.Since(start)) }(time.Now())
+
+	licenseServerURL := a.env.Config().LicenseServerURL
+	if licenseServerURL == "" {
+		return nil, fmt.Errorf("no license server is configured for this cluster")
+	}
+	info, err := a.heartbeat(ctx, licenseServerURL)
+	if err != nil {
+		return nil, err
+	}
+	return &ec.RenewResponse{Info: info}, nil
+}
+
 // GetState returns the current state of the cluster's Pachyderm Enterprise key (ACTIVE, EXPIRED, or NONE)
 func (a *apiServer) GetState(ctx context.Context, req *ec.GetStateRequest) (resp *ec.GetStateResponse, retErr error) {
 	a.LogReq(req)
@@ -285,7 +849,11 @@ func (a *apiServer) GetState(ctx context.Context, req *ec.GetStateRequest) (resp
 		Info: &ec.TokenInfo{
 			Expires: record.Expires,
 		},
-		ActivationCode: record.ActivationCode,
+		ActivationCode:    record.ActivationCode,
+		AuthorizedSigners: record.AuthorizedSigners,
+		RootKID:           record.RootKID,
+		Features:          record.Features,
+		Limits:            record.Limits,
 	}
 	if time.Now().After(expiration) {
 		resp.State = ec.State_EXPIRED
@@ -295,6 +863,110 @@ func (a *apiServer) GetState(ctx context.Context, req *ec.GetStateRequest) (resp
 	return resp, nil
 }
 
+// WatchState implements the WatchState RPC. It streams a GetStateResponse to
+// the caller immediately, and again every time the enterprise state
+// transitions, a configured warning threshold is crossed, or the token
+// expires, until the caller cancels.
+func (a *apiServer) WatchState(req *ec.GetStateRequest, stream ec.API_WatchStateServer) error {
+	a.LogReq(req)
+
+	current, err := a.GetState(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(current); err != nil {
+		return err
+	}
+
+	ch := make(chan *ec.GetStateResponse, 10)
+	id := atomic.AddInt64(&a.nextSubscriberID, 1)
+	a.stateSubscribers.Store(id, ch)
+	defer a.stateSubscribers.Delete(id)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case resp := <-ch:
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// liveRecord returns the cached EnterpriseRecord along with whether it's
+// currently live (a token is activated and its expiration, computed the same
+// way GetState does, hasn't passed). Once a token expires with no
+// re-activation or heartbeat renewal to replace it, callers must stop
+// treating its features and limits as granted.
+func (a *apiServer) liveRecord() (*ec.EnterpriseRecord, bool) {
+	record, ok := a.enterpriseExpiration.Load().(*ec.EnterpriseRecord)
+	if !ok {
+		return nil, false
+	}
+	expiration, err := types.TimestampFromProto(record.Expires)
+	if err != nil || expiration.IsZero() || time.Now().After(expiration) {
+		return record, false
+	}
+	return record, true
+}
+
+// HasFeature reports whether the live enterprise token grants the named
+// capability (e.g. "auth" or "object-storage-tiering"). Callers that don't
+// know about a feature, that run before any token is activated, or whose
+// token has since expired, see it as disabled.
+func (a *apiServer) HasFeature(name string) bool {
+	record, live := a.liveRecord()
+	if !live {
+		return false
+	}
+	for _, f := range record.Features {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit returns the live enterprise token's quota for the named limit (e.g.
+// "max_pipelines"), and whether that limit was present in the token at all.
+// An expired token reports every limit as absent.
+func (a *apiServer) Limit(name string) (int64, bool) {
+	record, live := a.liveRecord()
+	if !live {
+		return 0, false
+	}
+	limit, ok := record.Limits[name]
+	return limit, ok
+}
+
+// ListSigningKeys returns the KIDs and validity windows of the root keys in
+// the embedded signing key directory, so operators can audit which key
+// signed the currently installed license.
+func (a *apiServer) ListSigningKeys(ctx context.Context, req *ec.ListSigningKeysRequest) (resp *ec.ListSigningKeysResponse, retErr error) {
+	a.LogReq(req)
+	defer func(start time.Time) { a.pachLogger.Log(req, resp, retErr, time.Since(start)) }(time.Now())
+
+	resp = &ec.ListSigningKeysResponse{}
+	for _, k := range signingKeys {
+		notBefore, err := types.TimestampProto(k.NotBefore)
+		if err != nil {
+			return nil, fmt.Errorf("could not convert NotBefore for key %q: %s", k.KID, err.Error())
+		}
+		notAfter, err := types.TimestampProto(k.NotAfter)
+		if err != nil {
+			return nil, fmt.Errorf("could not convert NotAfter for key %q: %s", k.KID, err.Error())
+		}
+		resp.Keys = append(resp.Keys, &ec.SigningKeyInfo{
+			Kid:       k.KID,
+			NotBefore: notBefore,
+			NotAfter:  notAfter,
+		})
+	}
+	return resp, nil
+}
+
 // Deactivate deletes the current cluster's enterprise token, and puts the
 // cluster in the "NONE" enterprise state. It also deletes all data in the
 // cluster, to avoid invalid cluster states. This call only makes sense for
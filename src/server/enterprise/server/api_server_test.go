@@ -0,0 +1,212 @@
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+// mustGenerateSigningKey generates a fresh RSA keypair for use as a root or
+// signer key in a test, returning the private key and its PEM-encoded public
+// key in the same format signingKeys and signerKey.Pub expect.
+func mustGenerateSigningKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("could not generate RSA key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("could not marshal public key: %v", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return priv, string(pemBytes)
+}
+
+// mustSign signs data with priv the same way validatePolicy and
+// validateActivationCode verify: an RSA-SHA256 PKCS1v15 signature,
+// base64-encoded.
+func mustSign(t *testing.T, priv *rsa.PrivateKey, data []byte) string {
+	t.Helper()
+	hashed := sha256.Sum256(data)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatalf("could not sign data: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+// withTestSigningKeys replaces the package's embedded signingKeys directory
+// for the duration of a test, so tests can sign against keypairs they hold
+// the private half of rather than the real, unexported root key.
+func withTestSigningKeys(t *testing.T, keys []signingKey) {
+	t.Helper()
+	orig := signingKeys
+	signingKeys = keys
+	t.Cleanup(func() { signingKeys = orig })
+}
+
+func signPolicy(t *testing.T, rootPriv *rsa.PrivateKey, policy signerPolicy) signerPolicy {
+	t.Helper()
+	unsigned := signerPolicy{Signers: policy.Signers, Threshold: policy.Threshold}
+	policyBytes, err := json.Marshal(unsigned)
+	if err != nil {
+		t.Fatalf("could not marshal signer policy: %v", err)
+	}
+	policy.PolicySignature = mustSign(t, rootPriv, policyBytes)
+	return policy
+}
+
+func TestValidatePolicy(t *testing.T) {
+	rootPriv, rootPub := mustGenerateSigningKey(t)
+	withTestSigningKeys(t, []signingKey{
+		{KID: "test-root", NotBefore: time.Time{}, NotAfter: time.Now().Add(time.Hour), PEM: rootPub},
+	})
+	_, signerPub := mustGenerateSigningKey(t)
+	policy := signPolicy(t, rootPriv, signerPolicy{
+		Signers:   []signerKey{{KID: "signer-1", Pub: signerPub}},
+		Threshold: 1,
+	})
+	if err := validatePolicy(policy, "test-root"); err != nil {
+		t.Fatalf("expected a validly-signed policy to verify, got: %v", err)
+	}
+
+	tampered := policy
+	tampered.Threshold = 2
+	if err := validatePolicy(tampered, "test-root"); err == nil {
+		t.Fatalf("expected a policy modified after signing to fail verification")
+	}
+}
+
+func TestValidateActivationCodeThreshold(t *testing.T) {
+	rootPriv, rootPub := mustGenerateSigningKey(t)
+	withTestSigningKeys(t, []signingKey{
+		{KID: "test-root", NotBefore: time.Time{}, NotAfter: time.Now().Add(time.Hour), PEM: rootPub},
+	})
+	signer1Priv, signer1Pub := mustGenerateSigningKey(t)
+	signer2Priv, signer2Pub := mustGenerateSigningKey(t)
+	policy := signPolicy(t, rootPriv, signerPolicy{
+		Signers: []signerKey{
+			{KID: "signer-1", Pub: signer1Pub},
+			{KID: "signer-2", Pub: signer2Pub},
+		},
+		Threshold: 2,
+	})
+	tok := token{
+		Expiry:       time.Now().Add(time.Hour).Format(time.RFC3339),
+		Features:     []string{"auth"},
+		Limits:       map[string]int64{"max_pipelines": 10},
+		signerPolicy: policy,
+	}
+	tokenBytes, err := json.Marshal(tok)
+	if err != nil {
+		t.Fatalf("could not marshal token: %v", err)
+	}
+	sigFor := func(priv *rsa.PrivateKey) string { return mustSign(t, priv, tokenBytes) }
+
+	buildCode := func(sigs []signerSignature) string {
+		code := activationCode{Token: string(tokenBytes), Signatures: sigs, RootKID: "test-root"}
+		codeBytes, err := json.Marshal(code)
+		if err != nil {
+			t.Fatalf("could not marshal activation code: %v", err)
+		}
+		return base64.StdEncoding.EncodeToString(codeBytes)
+	}
+
+	t.Run("threshold met", func(t *testing.T) {
+		validated, err := validateActivationCode(buildCode([]signerSignature{
+			{KID: "signer-1", Signature: sigFor(signer1Priv)},
+			{KID: "signer-2", Signature: sigFor(signer2Priv)},
+		}))
+		if err != nil {
+			t.Fatalf("expected 2-of-2 signatures to satisfy a threshold-2 policy, got: %v", err)
+		}
+		if len(validated.AuthorizedSigners) != 2 {
+			t.Fatalf("expected both signers to be authorized, got: %v", validated.AuthorizedSigners)
+		}
+	})
+
+	t.Run("threshold not met", func(t *testing.T) {
+		_, err := validateActivationCode(buildCode([]signerSignature{
+			{KID: "signer-1", Signature: sigFor(signer1Priv)},
+		}))
+		if err == nil {
+			t.Fatalf("expected a single signature to fail a threshold-2 policy")
+		}
+	})
+
+	t.Run("unknown signer kid does not count toward threshold", func(t *testing.T) {
+		_, err := validateActivationCode(buildCode([]signerSignature{
+			{KID: "signer-1", Signature: sigFor(signer1Priv)},
+			{KID: "not-in-policy", Signature: sigFor(signer2Priv)},
+		}))
+		if err == nil {
+			t.Fatalf("expected a signature from a KID outside the policy to be ignored, not counted")
+		}
+	})
+}
+
+func TestLookupSigningKey(t *testing.T) {
+	_, pub := mustGenerateSigningKey(t)
+	withTestSigningKeys(t, []signingKey{
+		{KID: "current", NotBefore: time.Time{}, NotAfter: time.Now().Add(time.Hour), PEM: pub},
+		{KID: "retired", NotBefore: time.Time{}, NotAfter: time.Now().Add(-time.Hour), PEM: pub},
+	})
+
+	if _, err := lookupSigningKey("current"); err != nil {
+		t.Fatalf("expected a key inside its validity window to be found, got: %v", err)
+	}
+	if _, err := lookupSigningKey("retired"); err == nil {
+		t.Fatalf("expected a key past its NotAfter to be rejected")
+	}
+	if _, err := lookupSigningKey("unknown"); err == nil {
+		t.Fatalf("expected an unknown KID to be rejected")
+	}
+}
+
+func TestValidateActivationCodeLegacyRootKID(t *testing.T) {
+	rootPriv, rootPub := mustGenerateSigningKey(t)
+	withTestSigningKeys(t, []signingKey{
+		{KID: legacyRootKID, NotBefore: time.Time{}, NotAfter: time.Now().Add(time.Hour), PEM: rootPub},
+	})
+	signerPriv, signerPub := mustGenerateSigningKey(t)
+	policy := signPolicy(t, rootPriv, signerPolicy{
+		Signers:   []signerKey{{KID: "signer-1", Pub: signerPub}},
+		Threshold: 1,
+	})
+	tok := token{
+		Expiry:       time.Now().Add(time.Hour).Format(time.RFC3339),
+		signerPolicy: policy,
+	}
+	tokenBytes, err := json.Marshal(tok)
+	if err != nil {
+		t.Fatalf("could not marshal token: %v", err)
+	}
+	code := activationCode{
+		Token: string(tokenBytes),
+		Signatures: []signerSignature{
+			{KID: "signer-1", Signature: mustSign(t, signerPriv, tokenBytes)},
+		},
+		// RootKID intentionally left empty: codes issued before key
+		// rotation don't carry one, and must fall back to legacyRootKID.
+	}
+	codeBytes, err := json.Marshal(code)
+	if err != nil {
+		t.Fatalf("could not marshal activation code: %v", err)
+	}
+
+	validated, err := validateActivationCode(base64.StdEncoding.EncodeToString(codeBytes))
+	if err != nil {
+		t.Fatalf("expected an activation code with no RootKID to validate against legacyRootKID, got: %v", err)
+	}
+	if validated.RootKID != legacyRootKID {
+		t.Fatalf("expected RootKID to resolve to legacyRootKID, got: %q", validated.RootKID)
+	}
+}